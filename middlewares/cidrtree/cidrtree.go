@@ -0,0 +1,107 @@
+// Package cidrtree provides a binary patricia trie for longest-prefix-match
+// lookups over large sets of CIDR ranges, as an O(prefix-length) alternative
+// to scanning a []*net.IPNet linearly.
+package cidrtree
+
+import "net"
+
+// node is a single bit position in the trie. children[0] and children[1]
+// hold the subtrees reached by the next 0 or 1 bit of an IP address; match
+// is set when a CIDR was inserted with its prefix ending at this node.
+type node struct {
+	children [2]*node
+	match    bool
+}
+
+// Tree is a set of CIDR ranges, indexed for longest-prefix-match lookups.
+// IPv4 and IPv6 addresses are kept in separate trees since they are bit
+// strings of different lengths. The zero value is an empty Tree.
+type Tree struct {
+	v4 node
+	v6 node
+}
+
+// New builds a Tree from a list of CIDR strings.
+func New(cidrs []string) (*Tree, error) {
+	t := &Tree{}
+	for _, cidr := range cidrs {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, err
+		}
+		t.InsertNet(ipNet)
+	}
+	return t, nil
+}
+
+// InsertNet adds ipNet to the tree.
+func (t *Tree) InsertNet(ipNet *net.IPNet) {
+	ones, totalBits := ipNet.Mask.Size()
+	isV4, bs, prefixLen := normalize(ipNet.IP, ones, totalBits)
+
+	root := &t.v6
+	if isV4 {
+		root = &t.v4
+	}
+
+	n := root
+	for i := 0; i < prefixLen; i++ {
+		bit := bitAt(bs, i)
+		if n.children[bit] == nil {
+			n.children[bit] = &node{}
+		}
+		n = n.children[bit]
+	}
+	n.match = true
+}
+
+// normalize picks the byte representation to walk (4-byte IPv4 or 16-byte
+// IPv6) and the prefix length within it. A mask written in IPv6 syntax but
+// covering an IPv4-mapped address (e.g. "::ffff:10.0.0.0/104") is collapsed
+// to its plain-IPv4 equivalent (here, 10.0.0.0/8) so it's stored - and later
+// matched - consistently with ordinary, non-mapped IPv4 lookups, rather than
+// walking a mask sized for one byte slice against a shorter one and running
+// past its end.
+func normalize(ip net.IP, ones, totalBits int) (isV4 bool, bs []byte, prefixLen int) {
+	if totalBits == net.IPv4len*8 {
+		return true, ip.To4(), ones
+	}
+	if mapped := ip.To4(); mapped != nil && ones >= totalBits-net.IPv4len*8 {
+		return true, mapped, ones - (totalBits - net.IPv4len*8)
+	}
+	return false, ip.To16(), ones
+}
+
+// Contains reports whether ip matches any CIDR inserted into the tree, using
+// longest-prefix match: the deepest node reached along ip's bit path whose
+// match flag is set determines the result.
+func (t *Tree) Contains(ip net.IP) bool {
+	root := &t.v4
+	bs := ip.To4()
+	if bs == nil {
+		root = &t.v6
+		bs = ip.To16()
+	}
+	if bs == nil {
+		return false
+	}
+
+	matched := root.match
+	n := root
+	for i := 0; i < len(bs)*8; i++ {
+		next := n.children[bitAt(bs, i)]
+		if next == nil {
+			break
+		}
+		n = next
+		if n.match {
+			matched = true
+		}
+	}
+	return matched
+}
+
+// bitAt returns the i-th most significant bit of bs, counting from 0.
+func bitAt(bs []byte, i int) byte {
+	return (bs[i/8] >> uint(7-i%8)) & 1
+}
@@ -0,0 +1,76 @@
+package cidrtree
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTreeContains(t *testing.T) {
+	cases := []struct {
+		desc     string
+		cidrs    []string
+		matchIPs []string
+		missIPs  []string
+	}{
+		{
+			desc:     "IPv4",
+			cidrs:    []string{"1.2.3.4/24"},
+			matchIPs: []string{"1.2.3.1", "1.2.3.255"},
+			missIPs:  []string{"1.2.4.1", "8.8.8.8"},
+		},
+		{
+			desc:     "IPv4 single IP",
+			cidrs:    []string{"8.8.8.8/32"},
+			matchIPs: []string{"8.8.8.8"},
+			missIPs:  []string{"8.8.8.7", "8.8.8.9"},
+		},
+		{
+			desc:     "IPv6",
+			cidrs:    []string{"2a03:4000:6:d080::/64"},
+			matchIPs: []string{"2a03:4000:6:d080::1", "2a03:4000:6:d080:dead:beef:ffff:ffff"},
+			missIPs:  []string{"2a03:4000:7:d080::1", "fe80::1"},
+		},
+		{
+			desc:     "longest prefix match",
+			cidrs:    []string{"10.0.0.0/8", "10.0.5.0/24"},
+			matchIPs: []string{"10.0.0.1", "10.0.5.1"},
+			missIPs:  []string{"8.8.8.8"},
+		},
+		{
+			desc:     "mixed IPv4 and IPv6",
+			cidrs:    []string{"1.2.3.4/24", "fe80::/16"},
+			matchIPs: []string{"1.2.3.1", "fe80::1"},
+			missIPs:  []string{"8.8.8.8", "4242::1"},
+		},
+		{
+			desc:     "IPv4-mapped IPv6 CIDR with a prefix beyond 32 bits",
+			cidrs:    []string{"::ffff:10.0.0.0/104"},
+			matchIPs: []string{"::ffff:10.0.0.1", "::ffff:10.0.255.255"},
+			missIPs:  []string{"::ffff:11.0.0.1", "fe80::1"},
+		},
+	}
+
+	for _, test := range cases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+			tree, err := New(test.cidrs)
+			require.NoError(t, err)
+
+			for _, ipStr := range test.matchIPs {
+				assert.True(t, tree.Contains(net.ParseIP(ipStr)), ipStr+" should match")
+			}
+			for _, ipStr := range test.missIPs {
+				assert.False(t, tree.Contains(net.ParseIP(ipStr)), ipStr+" should not match")
+			}
+		})
+	}
+}
+
+func TestNewInvalidCIDR(t *testing.T) {
+	_, err := New([]string{"foo"})
+	require.Error(t, err)
+}
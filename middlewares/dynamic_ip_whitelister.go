@@ -0,0 +1,153 @@
+package middlewares
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/containous/traefik/log"
+	"github.com/containous/traefik/middlewares/cidrtree"
+	"github.com/urfave/negroni"
+)
+
+// allowListSnapshot is the compiled, ready-to-serve form of a whitelist
+// refresh: the parsed CIDRs (kept for introspection), the trie used for
+// lookups, and when the refresh that produced it succeeded.
+type allowListSnapshot struct {
+	nets        []*net.IPNet
+	tree        *cidrtree.Tree
+	lastRefresh time.Time
+}
+
+// DynamicIPWhitelister is an IP whitelist middleware whose allow list is
+// periodically refreshed from a WhitelistSource - a file, URL, or Kubernetes
+// ConfigMap - instead of being fixed at construction time. The compiled
+// allow list is swapped atomically so in-flight requests always see a
+// complete, consistent set, and a failed refresh keeps serving the
+// last-known-good one.
+type DynamicIPWhitelister struct {
+	source   WhitelistSource
+	strategy ipStrategy
+	handler  negroni.Handler
+
+	snapshot atomic.Value // holds *allowListSnapshot
+	stop     chan struct{}
+}
+
+// NewDynamicIPWhitelister builds a DynamicIPWhitelister that refreshes its
+// allow list from source every refreshInterval. The initial fetch happens
+// synchronously, so the middleware is ready to serve as soon as this
+// returns; subsequent refreshes run in the background until Close is called.
+func NewDynamicIPWhitelister(source WhitelistSource, refreshInterval time.Duration, useXForwardedFor bool, forwarded ForwardedHeaders) (*DynamicIPWhitelister, error) {
+	strategy, err := newIPStrategy(useXForwardedFor, forwarded)
+	if err != nil {
+		return nil, err
+	}
+
+	wl := &DynamicIPWhitelister{
+		source:   source,
+		strategy: strategy,
+		stop:     make(chan struct{}),
+	}
+	wl.handler = negroni.HandlerFunc(wl.handle)
+
+	if err := wl.refresh(context.Background()); err != nil {
+		return nil, err
+	}
+
+	go wl.refreshLoop(refreshInterval)
+
+	return wl, nil
+}
+
+// Close stops the background refresh loop. Callers must invoke it once the
+// DynamicIPWhitelister is no longer needed (e.g. on a dynamic configuration
+// reload that replaces it), otherwise its refresh goroutine runs forever.
+func (wl *DynamicIPWhitelister) Close() error {
+	close(wl.stop)
+	return nil
+}
+
+func (wl *DynamicIPWhitelister) refreshLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := wl.refresh(context.Background()); err != nil {
+				log.Errorf("whitelist refresh failed, keeping last-known-good set: %s", err)
+			}
+		case <-wl.stop:
+			return
+		}
+	}
+}
+
+func (wl *DynamicIPWhitelister) refresh(ctx context.Context) error {
+	cidrs, err := wl.source.Fetch(ctx)
+	if IsNotModified(err) {
+		current, ok := wl.snapshot.Load().(*allowListSnapshot)
+		if !ok {
+			return errors.New("whitelist source reported not modified before any successful fetch")
+		}
+		wl.storeSnapshot(current.nets, current.tree)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	nets, err := parseCIDRs(cidrs, "allow")
+	if err != nil {
+		return err
+	}
+
+	wl.storeSnapshot(nets, treeFromNets(nets))
+	return nil
+}
+
+func (wl *DynamicIPWhitelister) storeSnapshot(nets []*net.IPNet, tree *cidrtree.Tree) {
+	wl.snapshot.Store(&allowListSnapshot{
+		nets:        nets,
+		tree:        tree,
+		lastRefresh: time.Now(),
+	})
+}
+
+func (wl *DynamicIPWhitelister) current() *allowListSnapshot {
+	return wl.snapshot.Load().(*allowListSnapshot)
+}
+
+// Count returns the number of CIDR entries in the currently active allow
+// list, for exposure on the /health endpoint.
+func (wl *DynamicIPWhitelister) Count() int {
+	return len(wl.current().nets)
+}
+
+// LastRefresh returns the time of the last successful refresh, for exposure
+// on the /health endpoint.
+func (wl *DynamicIPWhitelister) LastRefresh() time.Time {
+	return wl.current().lastRefresh
+}
+
+func (wl *DynamicIPWhitelister) handle(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	clientIP := wl.strategy.clientIP(r)
+
+	if ip := net.ParseIP(clientIP); ip != nil && wl.current().tree.Contains(ip) {
+		log.Debugf("request %s matched dynamic whitelist - passing", clientIP)
+		next.ServeHTTP(w, r)
+		return
+	}
+
+	log.Debugf("request %s matched no dynamic whitelist entry - rejecting", clientIP)
+	reject(w)
+}
+
+// ServeHTTP implements the negroni handler interface.
+func (wl *DynamicIPWhitelister) ServeHTTP(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	wl.handler.ServeHTTP(w, r, next)
+}
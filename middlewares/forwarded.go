@@ -0,0 +1,211 @@
+package middlewares
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+const (
+	xForwardedForHeader = "X-Forwarded-For"
+	xRealIPHeader       = "X-Real-Ip"
+	forwardedHeader     = "Forwarded"
+)
+
+// ForwardedHeaders configures how the effective client IP is resolved from a
+// request's RemoteAddr together with its forwarding headers. The zero value
+// trusts no proxy and applies the historical "rightmost entry wins" behavior
+// of X-Forwarded-For/X-Real-Ip, with the RFC 7239 Forwarded header's "for"
+// entries also included in the chain.
+type ForwardedHeaders struct {
+	// TrustedIPs lists the CIDRs of the proxies allowed to set forwarding
+	// headers. Hops outside of this list stop the walk described below.
+	TrustedIPs []string
+	// Depth caps the number of proxy hops popped off the forwarding chain.
+	// 0 means unlimited.
+	Depth int
+	// DisableForwarded skips the RFC 7239 Forwarded header, leaving only
+	// X-Forwarded-For/X-Real-Ip in the chain.
+	DisableForwarded bool
+	// PreferXRealIP makes X-Real-Ip take precedence over Forwarded/X-Forwarded-For
+	// when both are present.
+	PreferXRealIP bool
+}
+
+// ipStrategy is the compiled, ready-to-use form of ForwardedHeaders.
+type ipStrategy struct {
+	useForwardingHeaders bool
+	disableForwarded     bool
+	preferXRealIP        bool
+	trustedProxies       []*net.IPNet
+	maxHops              int
+}
+
+func newIPStrategy(useForwardingHeaders bool, forwarded ForwardedHeaders) (ipStrategy, error) {
+	strategy := ipStrategy{
+		useForwardingHeaders: useForwardingHeaders,
+		disableForwarded:     forwarded.DisableForwarded,
+		preferXRealIP:        forwarded.PreferXRealIP,
+		maxHops:              forwarded.Depth,
+	}
+
+	for _, trustedIP := range forwarded.TrustedIPs {
+		_, trustedNet, err := net.ParseCIDR(trustedIP)
+		if err != nil {
+			return ipStrategy{}, fmt.Errorf("parsing CIDR trusted proxy %s: %v", trustedIP, err)
+		}
+		strategy.trustedProxies = append(strategy.trustedProxies, trustedNet)
+	}
+
+	return strategy, nil
+}
+
+// clientIP returns the effective client IP for req according to the strategy.
+func (s ipStrategy) clientIP(req *http.Request) string {
+	remoteIP := hostOnly(req.RemoteAddr)
+
+	if !s.useForwardingHeaders {
+		return remoteIP
+	}
+
+	// X-Real-Ip carries no chain of its own to walk, so it can only be
+	// trusted when RemoteAddr itself is a trusted proxy (or none are
+	// configured, preserving the historical fully-trusting behavior).
+	// Otherwise it's indistinguishable from an attacker-supplied value.
+	trustHeaders := len(s.trustedProxies) == 0 || s.isTrustedProxy(remoteIP)
+
+	if trustHeaders && s.preferXRealIP {
+		if realIP := strings.TrimSpace(req.Header.Get(xRealIPHeader)); realIP != "" {
+			return realIP
+		}
+	}
+
+	if chain := s.forwardingChain(req); len(chain) > 0 {
+		return s.resolve(remoteIP, chain)
+	}
+
+	if trustHeaders {
+		if realIP := strings.TrimSpace(req.Header.Get(xRealIPHeader)); realIP != "" {
+			return realIP
+		}
+	}
+
+	return remoteIP
+}
+
+// forwardingChain builds the combined chain of client IPs carried by the
+// Forwarded and X-Forwarded-For headers, ordered oldest hop (left) to newest
+// hop (right), matching the order in which proxies append to them.
+func (s ipStrategy) forwardingChain(req *http.Request) []string {
+	var chain []string
+
+	if !s.disableForwarded {
+		chain = append(chain, parseForwardedFor(req.Header.Get(forwardedHeader))...)
+	}
+
+	if xff := req.Header.Get(xForwardedForHeader); xff != "" {
+		for _, entry := range strings.Split(xff, ",") {
+			if ip := strings.TrimSpace(entry); ip != "" {
+				chain = append(chain, ip)
+			}
+		}
+	}
+
+	return chain
+}
+
+// resolve walks chain right-to-left starting from remoteIP, popping hops
+// that originate from a trusted proxy, and returns the first one that
+// doesn't. With no trusted proxies configured, the chain is trusted outright
+// and its rightmost entry is returned, preserving the historical "last IP
+// wins" behavior.
+func (s ipStrategy) resolve(remoteIP string, chain []string) string {
+	if len(s.trustedProxies) == 0 {
+		return chain[len(chain)-1]
+	}
+
+	current := remoteIP
+	for hops := 0; len(chain) > 0 && s.isTrustedProxy(current); hops++ {
+		if s.maxHops > 0 && hops >= s.maxHops {
+			break
+		}
+		current, chain = chain[len(chain)-1], chain[:len(chain)-1]
+	}
+	return current
+}
+
+func (s ipStrategy) isTrustedProxy(ipStr string) bool {
+	parsed := net.ParseIP(ipStr)
+	if parsed == nil {
+		return false
+	}
+	for _, proxy := range s.trustedProxies {
+		if proxy.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseForwardedFor extracts the ordered list of client IPs carried by the
+// "for" parameter of an RFC 7239 Forwarded header, oldest hop first.
+// Obfuscated identifiers (those starting with "_", e.g. "_unknown") carry no
+// usable address and are skipped.
+func parseForwardedFor(header string) []string {
+	if header == "" {
+		return nil
+	}
+
+	var chain []string
+	for _, forwardedPair := range strings.Split(header, ",") {
+		for _, part := range strings.Split(forwardedPair, ";") {
+			name, value, found := splitParam(part)
+			if !found || !strings.EqualFold(name, "for") {
+				continue
+			}
+
+			if ip := parseForwardedForValue(value); ip != "" {
+				chain = append(chain, ip)
+			}
+		}
+	}
+	return chain
+}
+
+func splitParam(part string) (name, value string, found bool) {
+	i := strings.IndexByte(part, '=')
+	if i < 0 {
+		return "", "", false
+	}
+	return strings.TrimSpace(part[:i]), strings.TrimSpace(part[i+1:]), true
+}
+
+func parseForwardedForValue(value string) string {
+	value = strings.Trim(value, `"`)
+	if value == "" || value == "unknown" || strings.HasPrefix(value, "_") {
+		return ""
+	}
+
+	if strings.HasPrefix(value, "[") {
+		// Bracketed IPv6 literal, optionally followed by ":port".
+		if end := strings.Index(value, "]"); end != -1 {
+			return value[1:end]
+		}
+		return ""
+	}
+
+	// IPv4 literal, optionally followed by ":port".
+	if host, _, err := net.SplitHostPort(value); err == nil {
+		return host
+	}
+	return value
+}
+
+// hostOnly strips the port, if any, from a RemoteAddr-style "host:port" pair.
+func hostOnly(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
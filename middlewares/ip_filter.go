@@ -0,0 +1,172 @@
+package middlewares
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/containous/traefik/log"
+	"github.com/containous/traefik/middlewares/cidrtree"
+	"github.com/urfave/negroni"
+)
+
+// Precedence controls which of Allow and Deny has the final say when a
+// client IP matches both, mirroring Apache's "Order" directive.
+type Precedence int
+
+const (
+	// AllowThenDeny evaluates Allow first and lets Deny override it: a
+	// configured Allow list rejects everything outside of it, and Deny then
+	// carves out exceptions within it (e.g. "allow 10.0.0.0/8 except
+	// 10.0.5.0/24"). This is the default.
+	AllowThenDeny Precedence = iota
+	// DenyThenAllow evaluates Deny first and lets Allow override it: Deny
+	// rejects outright, and Allow then carves out exceptions within it.
+	// Unlike Apache's "Order Deny,Allow", an IP matching neither list is
+	// rejected whenever an Allow list is configured.
+	DenyThenAllow
+)
+
+// IPFilter is a middleware that accepts or rejects requests based on the
+// client's IP, combining an allow list and a deny list with a configurable
+// precedence between them. IPWhitelister and NewIPBlacklister build on top
+// of it for the allow-only and deny-only cases.
+type IPFilter struct {
+	allow      []*net.IPNet
+	deny       []*net.IPNet
+	allowTree  *cidrtree.Tree
+	denyTree   *cidrtree.Tree
+	precedence Precedence
+	strategy   ipStrategy
+	handler    negroni.Handler
+}
+
+// NewIPFilter builds an IPFilter from CIDR-string allow and deny lists. At
+// least one of the two must be non-empty.
+func NewIPFilter(allow, deny []string, precedence Precedence, useXForwardedFor bool, forwarded ForwardedHeaders) (*IPFilter, error) {
+	if len(allow) == 0 && len(deny) == 0 {
+		return nil, errors.New("no whitelists provided")
+	}
+
+	strategy, err := newIPStrategy(useXForwardedFor, forwarded)
+	if err != nil {
+		return nil, err
+	}
+
+	allowNets, err := parseCIDRs(allow, "allow")
+	if err != nil {
+		return nil, err
+	}
+
+	denyNets, err := parseCIDRs(deny, "deny")
+	if err != nil {
+		return nil, err
+	}
+
+	filter := IPFilter{
+		allow:      allowNets,
+		deny:       denyNets,
+		allowTree:  treeFromNets(allowNets),
+		denyTree:   treeFromNets(denyNets),
+		precedence: precedence,
+		strategy:   strategy,
+	}
+	filter.handler = negroni.HandlerFunc(filter.handle)
+
+	log.Debugf("configured IP filter: allow=%v deny=%v precedence=%v", allow, deny, precedence)
+
+	return &filter, nil
+}
+
+// NewIPBlacklister builds an IPFilter that rejects requests from the given
+// CIDR-string deny list and allows everything else.
+func NewIPBlacklister(blacklist []string, useXForwardedFor bool) (*IPFilter, error) {
+	if len(blacklist) == 0 {
+		return nil, errors.New("no blacklists provided")
+	}
+	return NewIPFilter(nil, blacklist, AllowThenDeny, useXForwardedFor, ForwardedHeaders{})
+}
+
+func parseCIDRs(cidrs []string, list string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, cidr := range cidrs {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("parsing CIDR %s list %s: %v", list, cidr, err)
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets, nil
+}
+
+// treeFromNets builds a cidrtree.Tree from already-parsed CIDRs, once, so
+// request-time lookups are O(prefix-length) instead of O(len(nets)).
+func treeFromNets(nets []*net.IPNet) *cidrtree.Tree {
+	tree := &cidrtree.Tree{}
+	for _, ipNet := range nets {
+		tree.InsertNet(ipNet)
+	}
+	return tree
+}
+
+func (f *IPFilter) handle(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	clientIP := f.strategy.clientIP(r)
+
+	if f.isAuthorized(clientIP) {
+		log.Debugf("request %s passed IP filter - passing", clientIP)
+		next.ServeHTTP(w, r)
+		return
+	}
+
+	log.Debugf("request %s rejected by IP filter", clientIP)
+	reject(w)
+}
+
+// ServeHTTP implements the negroni handler interface.
+func (f *IPFilter) ServeHTTP(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	f.handler.ServeHTTP(w, r, next)
+}
+
+func (f *IPFilter) isAuthorized(clientIP string) bool {
+	ip := net.ParseIP(clientIP)
+	if ip == nil {
+		return false
+	}
+
+	allowed := f.allowTree.Contains(ip)
+	denied := f.denyTree.Contains(ip)
+
+	if f.precedence == DenyThenAllow {
+		if allowed {
+			return true
+		}
+		if denied {
+			return false
+		}
+		return len(f.allow) == 0
+	}
+
+	// AllowThenDeny
+	if len(f.allow) > 0 && !allowed {
+		return false
+	}
+	return !denied
+}
+
+func containsIP(nets []*net.IPNet, ip net.IP) bool {
+	for _, ipNet := range nets {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func reject(w http.ResponseWriter) {
+	w.WriteHeader(http.StatusForbidden)
+	_, err := w.Write([]byte(http.StatusText(http.StatusForbidden)))
+	if err != nil {
+		log.Errorf("error writing IP filter rejection response: %s", err)
+	}
+}
@@ -0,0 +1,52 @@
+package middlewares
+
+import (
+	"fmt"
+	"net"
+	"testing"
+)
+
+// benchCIDRs generates n disjoint /32 CIDRs for benchmarking list-scan
+// strategies against the cidrtree-backed one.
+func benchCIDRs(n int) []string {
+	cidrs := make([]string, n)
+	for i := 0; i < n; i++ {
+		cidrs[i] = fmt.Sprintf("10.%d.%d.%d/32", (i>>16)&0xff, (i>>8)&0xff, i&0xff)
+	}
+	return cidrs
+}
+
+func benchmarkContainsIPLinear(b *testing.B, n int) {
+	nets, err := parseCIDRs(benchCIDRs(n), "allow")
+	if err != nil {
+		b.Fatal(err)
+	}
+	needle := net.ParseIP("8.8.8.8")
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		containsIP(nets, needle)
+	}
+}
+
+func benchmarkContainsIPTrie(b *testing.B, n int) {
+	nets, err := parseCIDRs(benchCIDRs(n), "allow")
+	if err != nil {
+		b.Fatal(err)
+	}
+	tree := treeFromNets(nets)
+	needle := net.ParseIP("8.8.8.8")
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tree.Contains(needle)
+	}
+}
+
+func BenchmarkContainsIPLinear10(b *testing.B)     { benchmarkContainsIPLinear(b, 10) }
+func BenchmarkContainsIPLinear1000(b *testing.B)   { benchmarkContainsIPLinear(b, 1000) }
+func BenchmarkContainsIPLinear100000(b *testing.B) { benchmarkContainsIPLinear(b, 100000) }
+
+func BenchmarkContainsIPTrie10(b *testing.B)     { benchmarkContainsIPTrie(b, 10) }
+func BenchmarkContainsIPTrie1000(b *testing.B)   { benchmarkContainsIPTrie(b, 1000) }
+func BenchmarkContainsIPTrie100000(b *testing.B) { benchmarkContainsIPTrie(b, 100000) }
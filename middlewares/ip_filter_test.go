@@ -0,0 +1,140 @@
+package middlewares
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/containous/traefik/testhelpers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/negroni"
+)
+
+func TestNewIPBlacklister(t *testing.T) {
+	cases := []struct {
+		desc             string
+		blacklistStrings []string
+		errMessage       string
+	}{
+		{
+			desc:             "nil blacklist",
+			blacklistStrings: nil,
+			errMessage:       "no blacklists provided",
+		}, {
+			desc:             "empty blacklist",
+			blacklistStrings: []string{},
+			errMessage:       "no blacklists provided",
+		}, {
+			desc: "blacklist containing an invalid string",
+			blacklistStrings: []string{
+				"foo",
+			},
+			errMessage: "parsing CIDR deny list foo: invalid CIDR address: foo",
+		}, {
+			desc: "IPv4 & IPv6 blacklist",
+			blacklistStrings: []string{
+				"1.2.3.4/24",
+				"fe80::/16",
+			},
+			errMessage: "",
+		},
+	}
+
+	for _, test := range cases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+			blacklister, err := NewIPBlacklister(test.blacklistStrings, false)
+			if test.errMessage != "" {
+				require.EqualError(t, err, test.errMessage)
+			} else {
+				require.NoError(t, err)
+				require.NotNil(t, blacklister)
+			}
+		})
+	}
+}
+
+func TestIPFilterHandle(t *testing.T) {
+	cases := []struct {
+		desc       string
+		allow      []string
+		deny       []string
+		precedence Precedence
+		passIPs    []string
+		rejectIPs  []string
+	}{
+		{
+			desc:      "allow only",
+			allow:     []string{"10.0.0.0/8"},
+			passIPs:   []string{"10.0.0.1", "10.255.255.255"},
+			rejectIPs: []string{"8.8.8.8"},
+		},
+		{
+			desc:      "deny only",
+			deny:      []string{"10.0.5.0/24"},
+			passIPs:   []string{"8.8.8.8", "10.0.0.1"},
+			rejectIPs: []string{"10.0.5.1", "10.0.5.255"},
+		},
+		{
+			desc:       "allow-then-deny carves an exception out of an allowed range",
+			allow:      []string{"10.0.0.0/8"},
+			deny:       []string{"10.0.5.0/24"},
+			precedence: AllowThenDeny,
+			passIPs:    []string{"10.0.0.1", "10.1.2.3"},
+			rejectIPs:  []string{"10.0.5.1", "8.8.8.8"},
+		},
+		{
+			desc:       "deny-then-allow lets an allowed range override a denied one",
+			allow:      []string{"10.0.5.128/25"},
+			deny:       []string{"10.0.5.0/24"},
+			precedence: DenyThenAllow,
+			passIPs:    []string{"10.0.5.200"},
+			rejectIPs:  []string{"10.0.5.1", "10.0.0.1", "8.8.8.8"},
+		},
+		{
+			desc:       "IPv4 and IPv6 mix",
+			allow:      []string{"10.0.0.0/8", "2001:db8::/32"},
+			deny:       []string{"2001:db8:dead::/48"},
+			precedence: AllowThenDeny,
+			passIPs:    []string{"10.0.0.1", "[2001:db8:1::1]"},
+			rejectIPs:  []string{"[2001:db8:dead::1]", "[fe80::1]", "8.8.8.8"},
+		},
+	}
+
+	for _, test := range cases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+			filter, err := NewIPFilter(test.allow, test.deny, test.precedence, false, ForwardedHeaders{})
+			require.NoError(t, err)
+			require.NotNil(t, filter)
+
+			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprintln(w, "traefik")
+			})
+			n := negroni.New(filter)
+			n.UseHandler(handler)
+
+			for _, testIP := range test.passIPs {
+				req := testhelpers.MustNewRequest(http.MethodGet, "/", nil)
+				req.RemoteAddr = testIP + ":2342"
+				recorder := httptest.NewRecorder()
+				n.ServeHTTP(recorder, req)
+
+				assert.Equal(t, http.StatusOK, recorder.Code, testIP+" should have passed "+test.desc)
+			}
+
+			for _, testIP := range test.rejectIPs {
+				req := testhelpers.MustNewRequest(http.MethodGet, "/", nil)
+				req.RemoteAddr = testIP + ":2342"
+				recorder := httptest.NewRecorder()
+				n.ServeHTTP(recorder, req)
+
+				assert.Equal(t, http.StatusForbidden, recorder.Code, testIP+" should not have passed "+test.desc)
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package middlewares
+
+import (
+	"errors"
+	"net"
+	"net/http"
+)
+
+// IPWhitelister is a middleware that limits allowed requests to defined IP
+// blocks. It is a thin wrapper around IPFilter configured with an allow list
+// only.
+type IPWhitelister struct {
+	whitelists []*net.IPNet
+	filter     *IPFilter
+}
+
+// NewIPWhitelister builds a new IPWhitelister given a list of CIDR-Strings to whitelist.
+// Client IPs are resolved from RemoteAddr, or, when useXForwardedFor is set,
+// from the rightmost entry of the combined RFC 7239 Forwarded and
+// X-Forwarded-For chain, falling back to X-Real-Ip when that chain is empty.
+// Use NewIPWhitelisterWithForwardedHeaders to trust specific proxies instead.
+func NewIPWhitelister(whitelist []string, useXForwardedFor bool) (*IPWhitelister, error) {
+	return NewIPWhitelisterWithForwardedHeaders(whitelist, useXForwardedFor, ForwardedHeaders{})
+}
+
+// NewIPWhitelisterWithForwardedHeaders builds a new IPWhitelister given a list
+// of CIDR-Strings to whitelist, additionally configuring how forwarding
+// headers (X-Forwarded-For, X-Real-Ip and RFC 7239 Forwarded) are trusted
+// when resolving the effective client IP.
+func NewIPWhitelisterWithForwardedHeaders(whitelist []string, useXForwardedFor bool, forwarded ForwardedHeaders) (*IPWhitelister, error) {
+	if len(whitelist) == 0 {
+		return nil, errors.New("no whitelists provided")
+	}
+
+	filter, err := NewIPFilter(whitelist, nil, AllowThenDeny, useXForwardedFor, forwarded)
+	if err != nil {
+		return nil, err
+	}
+
+	return &IPWhitelister{
+		whitelists: filter.allow,
+		filter:     filter,
+	}, nil
+}
+
+// ServeHTTP implements the negroni handler interface.
+func (wl *IPWhitelister) ServeHTTP(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	wl.filter.ServeHTTP(w, r, next)
+}
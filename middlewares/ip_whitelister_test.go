@@ -39,21 +39,21 @@ func TestNewIPWhitelister(t *testing.T) {
 				"fe80::/16",
 			},
 			expectedWhitelists: nil,
-			errMessage:         "parsing CIDR whitelist <nil>: invalid CIDR address: ",
+			errMessage:         "parsing CIDR allow list : invalid CIDR address: ",
 		}, {
 			desc: "whitelist containing only an empty string",
 			whitelistStrings: []string{
 				"",
 			},
 			expectedWhitelists: nil,
-			errMessage:         "parsing CIDR whitelist <nil>: invalid CIDR address: ",
+			errMessage:         "parsing CIDR allow list : invalid CIDR address: ",
 		}, {
 			desc: "whitelist containing an invalid string",
 			whitelistStrings: []string{
 				"foo",
 			},
 			expectedWhitelists: nil,
-			errMessage:         "parsing CIDR whitelist <nil>: invalid CIDR address: foo",
+			errMessage:         "parsing CIDR allow list foo: invalid CIDR address: foo",
 		}, {
 			desc: "IPv4 & IPv6 whitelist",
 			whitelistStrings: []string{
@@ -430,3 +430,150 @@ func TestIPWhitelisterHandleMultiIp(t *testing.T) {
 		})
 	}
 }
+
+func TestParseForwardedFor(t *testing.T) {
+	cases := []struct {
+		desc     string
+		header   string
+		expected []string
+	}{
+		{
+			desc:     "empty header",
+			header:   "",
+			expected: nil,
+		},
+		{
+			desc:     "single IPv4",
+			header:   `for=192.0.2.60`,
+			expected: []string{"192.0.2.60"},
+		},
+		{
+			desc:     "IPv4 with extra params",
+			header:   `for=192.0.2.60;proto=http;by=203.0.113.43`,
+			expected: []string{"192.0.2.60"},
+		},
+		{
+			desc:     "multiple hops",
+			header:   `for=192.0.2.60, for=198.51.100.17`,
+			expected: []string{"192.0.2.60", "198.51.100.17"},
+		},
+		{
+			desc:     "quoted IPv6 with port",
+			header:   `for="[2001:db8:cafe::17]:4711"`,
+			expected: []string{"2001:db8:cafe::17"},
+		},
+		{
+			desc:     "obfuscated identifier is ignored",
+			header:   `for=_hidden, for=192.0.2.60, for=_unknown`,
+			expected: []string{"192.0.2.60"},
+		},
+		{
+			desc:     "unknown keyword is ignored",
+			header:   `for=unknown, for=192.0.2.60`,
+			expected: []string{"192.0.2.60"},
+		},
+	}
+
+	for _, test := range cases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, test.expected, parseForwardedFor(test.header))
+		})
+	}
+}
+
+func TestIPWhitelisterTrustedProxies(t *testing.T) {
+	cases := []struct {
+		desc           string
+		trustedProxies []string
+		remoteAddr     string
+		xForwardedFor  string
+		forwarded      string
+		xRealIP        string
+		expectedPass   bool
+	}{
+		{
+			desc:           "untrusted remote address ignores X-Forwarded-For",
+			trustedProxies: []string{"10.0.0.0/8"},
+			remoteAddr:     "8.8.8.8:2342",
+			xForwardedFor:  "1.2.3.4",
+			expectedPass:   false,
+		},
+		{
+			desc:           "trusted proxy hands off to whitelisted client",
+			trustedProxies: []string{"10.0.0.0/8"},
+			remoteAddr:     "10.0.0.1:2342",
+			xForwardedFor:  "1.2.3.4",
+			expectedPass:   true,
+		},
+		{
+			desc:           "trusted proxy chain is popped until a non-trusted hop is found",
+			trustedProxies: []string{"10.0.0.0/8"},
+			remoteAddr:     "10.0.0.1:2342",
+			xForwardedFor:  "1.2.3.4, 10.0.0.2",
+			expectedPass:   true,
+		},
+		{
+			desc:           "Forwarded header is honored",
+			trustedProxies: []string{"10.0.0.0/8"},
+			remoteAddr:     "10.0.0.1:2342",
+			forwarded:      `for=1.2.3.4`,
+			expectedPass:   true,
+		},
+		{
+			desc:           "untrusted remote address cannot spoof X-Real-Ip",
+			trustedProxies: []string{"10.0.0.0/8"},
+			remoteAddr:     "8.8.8.8:2342",
+			xRealIP:        "1.2.3.4",
+			expectedPass:   false,
+		},
+		{
+			desc:           "trusted proxy directly setting X-Real-Ip is honored",
+			trustedProxies: []string{"10.0.0.0/8"},
+			remoteAddr:     "10.0.0.1:2342",
+			xRealIP:        "1.2.3.4",
+			expectedPass:   true,
+		},
+	}
+
+	for _, test := range cases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+			whitelister, err := NewIPWhitelisterWithForwardedHeaders(
+				[]string{"1.2.3.4/32"},
+				true,
+				ForwardedHeaders{TrustedIPs: test.trustedProxies},
+			)
+			require.NoError(t, err)
+
+			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprintln(w, "traefik")
+			})
+			n := negroni.New(whitelister)
+			n.UseHandler(handler)
+
+			req := testhelpers.MustNewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = test.remoteAddr
+			if test.xForwardedFor != "" {
+				req.Header.Set("X-Forwarded-For", test.xForwardedFor)
+			}
+			if test.forwarded != "" {
+				req.Header.Set("Forwarded", test.forwarded)
+			}
+			if test.xRealIP != "" {
+				req.Header.Set("X-Real-Ip", test.xRealIP)
+			}
+
+			recorder := httptest.NewRecorder()
+			n.ServeHTTP(recorder, req)
+
+			if test.expectedPass {
+				assert.Equal(t, http.StatusOK, recorder.Code)
+			} else {
+				assert.Equal(t, http.StatusForbidden, recorder.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,164 @@
+package middlewares
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// ErrWhitelistSourceNotModified is returned by a WhitelistSource.Fetch
+// implementation when the upstream list hasn't changed since the last
+// successful fetch. Use IsNotModified to check for it.
+var ErrWhitelistSourceNotModified = errors.New("whitelist source not modified")
+
+// IsNotModified reports whether err is ErrWhitelistSourceNotModified.
+func IsNotModified(err error) bool {
+	return err == ErrWhitelistSourceNotModified
+}
+
+// WhitelistSource fetches the current set of CIDR whitelist entries from an
+// external provider, so a DynamicIPWhitelister can refresh itself without a
+// restart. Implementations should return ErrWhitelistSourceNotModified when
+// they can detect that the list is unchanged, and any other error otherwise;
+// callers keep serving the last-known-good set on error.
+type WhitelistSource interface {
+	Fetch(ctx context.Context) ([]string, error)
+}
+
+// FileWhitelistSource reads one CIDR per line from a local file. Blank lines
+// and lines starting with "#" are ignored.
+type FileWhitelistSource struct {
+	Path string
+}
+
+// Fetch implements WhitelistSource.
+func (s FileWhitelistSource) Fetch(ctx context.Context) ([]string, error) {
+	f, err := os.Open(s.Path)
+	if err != nil {
+		return nil, fmt.Errorf("reading whitelist file %s: %v", s.Path, err)
+	}
+	defer f.Close()
+
+	var cidrs []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if cidr := stripComment(scanner.Text()); cidr != "" {
+			cidrs = append(cidrs, cidr)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading whitelist file %s: %v", s.Path, err)
+	}
+	return cidrs, nil
+}
+
+// URLWhitelistSource fetches one CIDR per line from an HTTP(S) URL. It
+// honors ETag/Last-Modified across calls so an unchanged list doesn't need
+// to be re-downloaded or re-parsed.
+type URLWhitelistSource struct {
+	URL    string
+	Client *http.Client
+
+	etag         string
+	lastModified string
+}
+
+// Fetch implements WhitelistSource.
+func (s *URLWhitelistSource) Fetch(ctx context.Context) ([]string, error) {
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	req, err := http.NewRequest(http.MethodGet, s.URL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("building request for whitelist URL %s: %v", s.URL, err)
+	}
+	req = req.WithContext(ctx)
+	if s.etag != "" {
+		req.Header.Set("If-None-Match", s.etag)
+	}
+	if s.lastModified != "" {
+		req.Header.Set("If-Modified-Since", s.lastModified)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetching whitelist URL %s: %v", s.URL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
+		return nil, ErrWhitelistSourceNotModified
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching whitelist URL %s: unexpected status %s", s.URL, resp.Status)
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading whitelist URL %s: %v", s.URL, err)
+	}
+
+	s.etag = resp.Header.Get("ETag")
+	s.lastModified = resp.Header.Get("Last-Modified")
+
+	return splitCIDRLines(string(body)), nil
+}
+
+// ConfigMapGetter is the subset of the Kubernetes provider client that
+// ConfigMapWhitelistSource needs, narrowed so it can be exercised with a
+// fake in tests instead of the full provider client surface.
+type ConfigMapGetter interface {
+	GetConfigMap(namespace, name string) (data map[string]string, exists bool, err error)
+}
+
+// ConfigMapWhitelistSource reads one CIDR per line from a key of a
+// Kubernetes ConfigMap, via the existing Kubernetes provider client.
+type ConfigMapWhitelistSource struct {
+	Client    ConfigMapGetter
+	Namespace string
+	Name      string
+	Key       string
+}
+
+// Fetch implements WhitelistSource.
+func (s ConfigMapWhitelistSource) Fetch(ctx context.Context) ([]string, error) {
+	configMap, exists, err := s.Client.GetConfigMap(s.Namespace, s.Name)
+	if err != nil {
+		return nil, fmt.Errorf("fetching ConfigMap %s/%s: %v", s.Namespace, s.Name, err)
+	}
+	if !exists {
+		return nil, fmt.Errorf("ConfigMap %s/%s not found", s.Namespace, s.Name)
+	}
+
+	data, ok := configMap[s.Key]
+	if !ok {
+		return nil, fmt.Errorf("ConfigMap %s/%s has no key %q", s.Namespace, s.Name, s.Key)
+	}
+
+	return splitCIDRLines(data), nil
+}
+
+func splitCIDRLines(text string) []string {
+	var cidrs []string
+	for _, line := range strings.Split(text, "\n") {
+		if cidr := stripComment(line); cidr != "" {
+			cidrs = append(cidrs, cidr)
+		}
+	}
+	return cidrs
+}
+
+func stripComment(line string) string {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ""
+	}
+	return line
+}
@@ -0,0 +1,219 @@
+package middlewares
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileWhitelistSourceFetch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "traefik-whitelist-source")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "whitelist.txt")
+	contents := "1.2.3.4/24\n# a comment\n\nfe80::/16\n"
+	require.NoError(t, ioutil.WriteFile(path, []byte(contents), 0644))
+
+	source := FileWhitelistSource{Path: path}
+	cidrs, err := source.Fetch(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1.2.3.4/24", "fe80::/16"}, cidrs)
+}
+
+func TestFileWhitelistSourceFetchMissing(t *testing.T) {
+	source := FileWhitelistSource{Path: "/does/not/exist"}
+	_, err := source.Fetch(context.Background())
+	require.Error(t, err)
+}
+
+func TestURLWhitelistSourceFetch(t *testing.T) {
+	var requests []*http.Request
+	body := "1.2.3.4/24\nfe80::/16\n"
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r)
+
+		if r.Header.Get("If-None-Match") == "etag-1" || r.Header.Get("If-Modified-Since") == "Mon, 01 Jan 2018 00:00:00 GMT" {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		w.Header().Set("ETag", "etag-1")
+		w.Header().Set("Last-Modified", "Mon, 01 Jan 2018 00:00:00 GMT")
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	source := &URLWhitelistSource{URL: server.URL}
+
+	cidrs, err := source.Fetch(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1.2.3.4/24", "fe80::/16"}, cidrs)
+	require.Len(t, requests, 1)
+	assert.Empty(t, requests[0].Header.Get("If-None-Match"))
+	assert.Empty(t, requests[0].Header.Get("If-Modified-Since"))
+
+	// The second fetch round-trips the ETag/Last-Modified it learned from
+	// the first response and gets told nothing changed.
+	_, err = source.Fetch(context.Background())
+	require.True(t, IsNotModified(err))
+	require.Len(t, requests, 2)
+	assert.Equal(t, "etag-1", requests[1].Header.Get("If-None-Match"))
+	assert.Equal(t, "Mon, 01 Jan 2018 00:00:00 GMT", requests[1].Header.Get("If-Modified-Since"))
+}
+
+func TestURLWhitelistSourceFetchError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	source := &URLWhitelistSource{URL: server.URL}
+	_, err := source.Fetch(context.Background())
+	require.Error(t, err)
+}
+
+// fakeConfigMapGetter is a test double for ConfigMapGetter.
+type fakeConfigMapGetter struct {
+	data   map[string]string
+	exists bool
+	err    error
+}
+
+func (g fakeConfigMapGetter) GetConfigMap(namespace, name string) (map[string]string, bool, error) {
+	return g.data, g.exists, g.err
+}
+
+func TestConfigMapWhitelistSourceFetch(t *testing.T) {
+	cases := []struct {
+		desc       string
+		getter     fakeConfigMapGetter
+		key        string
+		expected   []string
+		errMessage string
+	}{
+		{
+			desc:     "key present",
+			getter:   fakeConfigMapGetter{data: map[string]string{"whitelist": "1.2.3.4/24\nfe80::/16\n"}, exists: true},
+			key:      "whitelist",
+			expected: []string{"1.2.3.4/24", "fe80::/16"},
+		},
+		{
+			desc:       "config map missing",
+			getter:     fakeConfigMapGetter{exists: false},
+			key:        "whitelist",
+			errMessage: "ConfigMap ns/name not found",
+		},
+		{
+			desc:       "key missing",
+			getter:     fakeConfigMapGetter{data: map[string]string{"other": "1.2.3.4/24"}, exists: true},
+			key:        "whitelist",
+			errMessage: `ConfigMap ns/name has no key "whitelist"`,
+		},
+		{
+			desc:       "client error",
+			getter:     fakeConfigMapGetter{err: assert.AnError},
+			key:        "whitelist",
+			errMessage: "fetching ConfigMap ns/name: " + assert.AnError.Error(),
+		},
+	}
+
+	for _, test := range cases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			source := ConfigMapWhitelistSource{
+				Client:    test.getter,
+				Namespace: "ns",
+				Name:      "name",
+				Key:       test.key,
+			}
+
+			cidrs, err := source.Fetch(context.Background())
+			if test.errMessage != "" {
+				require.EqualError(t, err, test.errMessage)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, cidrs)
+		})
+	}
+}
+
+// fakeWhitelistSource is a test double that returns queued responses in
+// order, one per call to Fetch.
+type fakeWhitelistSource struct {
+	responses []fakeWhitelistResponse
+	calls     int
+}
+
+type fakeWhitelistResponse struct {
+	cidrs []string
+	err   error
+}
+
+func (s *fakeWhitelistSource) Fetch(ctx context.Context) ([]string, error) {
+	response := s.responses[s.calls]
+	s.calls++
+	return response.cidrs, response.err
+}
+
+func TestDynamicIPWhitelisterRefresh(t *testing.T) {
+	source := &fakeWhitelistSource{
+		responses: []fakeWhitelistResponse{
+			{cidrs: []string{"1.2.3.4/32"}},
+		},
+	}
+
+	wl, err := NewDynamicIPWhitelister(source, time.Hour, false, ForwardedHeaders{})
+	require.NoError(t, err)
+	defer wl.Close()
+
+	require.Equal(t, 1, wl.Count())
+	assert.False(t, wl.LastRefresh().IsZero())
+
+	// A failed refresh keeps the last-known-good set.
+	source.responses = append(source.responses, fakeWhitelistResponse{err: assert.AnError})
+	require.Error(t, wl.refresh(context.Background()))
+	assert.Equal(t, 1, wl.Count())
+
+	// A successful refresh swaps in the new set.
+	source.responses = append(source.responses, fakeWhitelistResponse{cidrs: []string{"1.2.3.4/32", "8.8.8.8/32"}})
+	require.NoError(t, wl.refresh(context.Background()))
+	assert.Equal(t, 2, wl.Count())
+}
+
+func TestNewDynamicIPWhitelisterInitialNotModifiedFails(t *testing.T) {
+	source := &fakeWhitelistSource{
+		responses: []fakeWhitelistResponse{
+			{err: ErrWhitelistSourceNotModified},
+		},
+	}
+
+	_, err := NewDynamicIPWhitelister(source, time.Hour, false, ForwardedHeaders{})
+	require.Error(t, err)
+}
+
+func TestDynamicIPWhitelisterClose(t *testing.T) {
+	source := &fakeWhitelistSource{
+		responses: []fakeWhitelistResponse{
+			{cidrs: []string{"1.2.3.4/32"}},
+		},
+	}
+
+	wl, err := NewDynamicIPWhitelister(source, time.Hour, false, ForwardedHeaders{})
+	require.NoError(t, err)
+
+	require.NoError(t, wl.Close())
+	assert.Equal(t, 1, wl.Count())
+}